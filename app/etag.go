@@ -0,0 +1,51 @@
+package app
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/sourcegraph/app/internal/ui"
+)
+
+// computeETag produces a strong ETag for a rendered page. It defaults
+// to md5-hashing the serialized response (head, body, stores, status),
+// the same approach gddo-server uses for its doc pages, but is
+// pluggable so a cheaper or more targeted strategy can be swapped in.
+var computeETag = md5ETag
+
+func md5ETag(res *ui.RenderResult) string {
+	serialized, _ := json.Marshal(struct {
+		Head       ui.Head
+		Body       string
+		Stores     json.RawMessage
+		StatusCode int
+	}{res.Head, res.Body, res.Stores, res.StatusCode})
+	sum := md5.Sum(serialized)
+	return fmt.Sprintf(`"%x"`, sum)
+}
+
+// condGETSatisfied reports whether r's conditional request headers
+// (If-None-Match, If-Modified-Since) are satisfied by etag/lastMod, in
+// which case the caller should respond 304 Not Modified instead of
+// re-sending the body.
+func condGETSatisfied(r *http.Request, etag string, lastMod time.Time) bool {
+	if inm := r.Header.Get("if-none-match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("if-modified-since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !lastMod.Truncate(time.Second).After(t)
+		}
+	}
+	return false
+}
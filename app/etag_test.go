@@ -0,0 +1,72 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCondGETSatisfied_IfNoneMatch(t *testing.T) {
+	const etag = `"abc123"`
+	lastMod := time.Now()
+
+	tests := []struct {
+		name       string
+		ifNoneMatch string
+		want       bool
+	}{
+		{"exact match", `"abc123"`, true},
+		{"weak prefix match", `W/"abc123"`, true},
+		{"wildcard", "*", true},
+		{"mismatch", `"other"`, false},
+		{"match among several", `"other", "abc123"`, true},
+		{"weak mismatch", `W/"other"`, false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("if-none-match", test.ifNoneMatch)
+		if got := condGETSatisfied(r, etag, lastMod); got != test.want {
+			t.Errorf("%s: condGETSatisfied(If-None-Match: %q) = %v, want %v", test.name, test.ifNoneMatch, got, test.want)
+		}
+	}
+}
+
+func TestCondGETSatisfied_IfModifiedSince(t *testing.T) {
+	lastMod := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		ims  time.Time
+		want bool
+	}{
+		{"exact second", lastMod, true},
+		{"after last-modified, truncated to same second", lastMod.Add(500 * time.Millisecond), true},
+		{"before last-modified", lastMod.Add(-time.Second), false},
+		{"well after last-modified", lastMod.Add(time.Hour), true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("if-modified-since", test.ims.UTC().Format(http.TimeFormat))
+		if got := condGETSatisfied(r, `"etag"`, lastMod); got != test.want {
+			t.Errorf("%s: condGETSatisfied(If-Modified-Since: %v) = %v, want %v", test.name, test.ims, got, test.want)
+		}
+	}
+}
+
+func TestCondGETSatisfied_NoConditionalHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if condGETSatisfied(r, `"etag"`, time.Now()) {
+		t.Error("condGETSatisfied() = true with no conditional headers set, want false")
+	}
+}
+
+func TestCondGETSatisfied_IfNoneMatchTakesPrecedence(t *testing.T) {
+	lastMod := time.Now()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("if-none-match", `"mismatch"`)
+	r.Header.Set("if-modified-since", lastMod.UTC().Format(http.TimeFormat))
+	if condGETSatisfied(r, `"etag"`, lastMod) {
+		t.Error("condGETSatisfied() = true when If-None-Match mismatches, want false even though If-Modified-Since would match")
+	}
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// SSRCacheResult counts lookups against the SSR response cache by
+// outcome: "hit" (fresh, served directly), "stale" (served while a
+// background refresh runs), or "miss" (rendered inline, then stored
+// only if the result is cacheable).
+var SSRCacheResult = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "app",
+	Name:      "ssr_cache_result_total",
+	Help:      "Outcome of SSR response cache lookups, by result (hit, stale, miss).",
+}, []string{"result"})
+
+// SSRCacheRefreshTotal counts background re-renders kicked off to
+// refill a stale cache entry.
+var SSRCacheRefreshTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "app",
+	Name:      "ssr_cache_refresh_total",
+	Help:      "Number of background re-renders started to refill a stale SSR cache entry.",
+})
+
+func init() {
+	prometheus.MustRegister(SSRCacheResult, SSRCacheRefreshTotal)
+}
@@ -0,0 +1,140 @@
+// Package metrics instruments the app package's HTTP handlers with
+// Prometheus metrics, following the same request-count/in-flight/size/
+// latency pattern Prometheus's own web package uses for its server.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	requestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "src",
+		Subsystem: "app",
+		Name:      "request_count",
+		Help:      "Number of HTTP requests handled by the app package, by route, method, and status class.",
+	}, []string{"route", "method", "status_class"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "src",
+		Subsystem: "app",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of app package HTTP requests, by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	responseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "src",
+		Subsystem: "app",
+		Name:      "response_size_bytes",
+		Help:      "Size of app package HTTP responses, by route.",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 8),
+	}, []string{"route"})
+
+	inFlightRequests = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "src",
+		Subsystem: "app",
+		Name:      "requests_in_flight",
+		Help:      "Number of app package HTTP requests currently being handled, by route.",
+	}, []string{"route"})
+)
+
+func init() {
+	prometheus.MustRegister(requestCount, requestDuration, responseSize, inFlightRequests)
+}
+
+// Handler serves the Prometheus text exposition format for all
+// metrics registered by this package (and any other package that
+// registers against the default registry).
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ClassifyStatus buckets an HTTP status code the way the whole app
+// package agrees on, so that error handling, content negotiation, and
+// metric labeling all share one definition of "what counts as
+// success". It lives here (rather than in the ui package, which would
+// create an import cycle since ui already imports metrics) so both ui
+// and app can depend on it.
+func ClassifyStatus(code int) (isSuccess, isRedirect, isClientErr, isServerErr bool) {
+	switch {
+	case code >= 200 && code < 300:
+		isSuccess = true
+	case code >= 300 && code < 400:
+		isRedirect = true
+	case code >= 400 && code < 500:
+		isClientErr = true
+	case code >= 500:
+		isServerErr = true
+	}
+	return
+}
+
+// statusClass returns the "2xx"/"4xx"/etc. label used on requestCount,
+// built from ClassifyStatus's buckets.
+func statusClass(code int) string {
+	switch isSuccess, isRedirect, isClientErr, isServerErr := ClassifyStatus(code); {
+	case isSuccess:
+		return "2xx"
+	case isRedirect:
+		return "3xx"
+	case isClientErr:
+		return "4xx"
+	case isServerErr:
+		return "5xx"
+	default:
+		return strconv.Itoa(code/100) + "xx"
+	}
+}
+
+// sizeRecorder wraps a http.ResponseWriter to capture the number of
+// bytes written, so responseSize can be observed after the handler
+// returns.
+type sizeRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (s *sizeRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *sizeRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.size += n
+	return n, err
+}
+
+// Instrument wraps an app handler (the app package's convention of
+// func(w, r) error, used with its errorHandler adapter) to record
+// request count, in-flight requests, response size, and latency for
+// routeName.
+func Instrument(routeName string, h func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		inFlightRequests.WithLabelValues(routeName).Inc()
+		defer inFlightRequests.WithLabelValues(routeName).Dec()
+
+		rec := &sizeRecorder{ResponseWriter: w}
+		start := time.Now()
+		err := h(rec, r)
+		requestDuration.WithLabelValues(routeName, r.Method).Observe(time.Since(start).Seconds())
+		responseSize.WithLabelValues(routeName).Observe(float64(rec.size))
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		requestCount.WithLabelValues(routeName, r.Method, statusClass(status)).Inc()
+		return err
+	}
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import "testing"
+
+func TestClassifyStatus(t *testing.T) {
+	tests := []struct {
+		code                                             int
+		isSuccess, isRedirect, isClientErr, isServerErr  bool
+	}{
+		{199, false, false, false, false},
+		{200, true, false, false, false},
+		{204, true, false, false, false},
+		{299, true, false, false, false},
+		{300, false, true, false, false},
+		{304, false, true, false, false},
+		{399, false, true, false, false},
+		{400, false, false, true, false},
+		{404, false, false, true, false},
+		{499, false, false, true, false},
+		{500, false, false, false, true},
+		{503, false, false, false, true},
+		{599, false, false, false, true},
+	}
+	for _, test := range tests {
+		isSuccess, isRedirect, isClientErr, isServerErr := ClassifyStatus(test.code)
+		if isSuccess != test.isSuccess || isRedirect != test.isRedirect || isClientErr != test.isClientErr || isServerErr != test.isServerErr {
+			t.Errorf("ClassifyStatus(%d) = (%v, %v, %v, %v), want (%v, %v, %v, %v)",
+				test.code, isSuccess, isRedirect, isClientErr, isServerErr,
+				test.isSuccess, test.isRedirect, test.isClientErr, test.isServerErr)
+		}
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{200, "2xx"},
+		{301, "3xx"},
+		{404, "4xx"},
+		{500, "5xx"},
+		{100, "1xx"},
+	}
+	for _, test := range tests {
+		if got := statusClass(test.code); got != test.want {
+			t.Errorf("statusClass(%d) = %q, want %q", test.code, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// PrerenderDuration observes how long the React SSR step takes,
+	// so the 2500ms*ciFactor budget serveUI enforces can be tuned
+	// against real data instead of guesswork.
+	PrerenderDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "src",
+		Subsystem: "app",
+		Name:      "prerender_duration_seconds",
+		Help:      "Time spent rendering a route's React component tree server-side.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+
+	// PrerenderTimeouts counts renders that hit context.DeadlineExceeded
+	// instead of completing, previously only visible via a log15.Warn line.
+	PrerenderTimeouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "src",
+		Subsystem: "app",
+		Name:      "prerender_timeouts_total",
+		Help:      "Number of React server-side renders that were abandoned because the deadline was exceeded.",
+	})
+
+	// StorePayloadSize observes the size of the serialized Redux store
+	// state sent down with each prerendered page.
+	StorePayloadSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "src",
+		Subsystem: "app",
+		Name:      "prerender_store_payload_bytes",
+		Help:      "Size of the serialized store payload returned alongside a prerendered page.",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 10),
+	})
+
+	// JSServerFallbackTotal counts requests served with React
+	// prerendering disabled via SG_DISABLE_JSSERVER.
+	JSServerFallbackTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "src",
+		Subsystem: "app",
+		Name:      "prerender_jsserver_fallback_total",
+		Help:      "Number of requests served via the SG_DISABLE_JSSERVER fallback path instead of React prerendering.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(PrerenderDuration, PrerenderTimeouts, StorePayloadSize, JSServerFallbackTotal)
+}
@@ -0,0 +1,199 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// httpRenderer is a Renderer that delegates SSR to a pool of remote
+// Node prerender workers over HTTP/JSON, instead of rendering
+// in-process. It supports per-worker concurrency limits and a circuit
+// breaker that takes a worker out of rotation once it has failed too
+// many times in a row; only when every worker is out of rotation does
+// it fall back to the client-only shell.
+type httpRenderer struct {
+	workers []*renderWorker
+	timeout time.Duration
+	client  *http.Client
+
+	next uint64 // round-robin cursor, incremented atomically
+}
+
+type renderWorker struct {
+	url       string
+	sem       chan struct{} // bounds concurrent in-flight requests
+	threshold int
+
+	mu             sync.Mutex
+	consecFailures int
+	healthy        bool
+}
+
+func newHTTPRenderer(cfg Config) (*httpRenderer, error) {
+	if len(cfg.WorkerURLs) == 0 {
+		return nil, fmt.Errorf("ui: http render backend requires at least one worker URL")
+	}
+	concurrency := cfg.WorkerConcurrency
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+	threshold := cfg.CircuitBreakerThreshold
+	if threshold <= 0 {
+		threshold = 5
+	}
+	timeout := cfg.RenderTimeout
+	if timeout <= 0 {
+		timeout = 2500 * time.Millisecond
+	}
+
+	hr := &httpRenderer{
+		timeout: timeout,
+		client:  &http.Client{Timeout: timeout + 500*time.Millisecond},
+	}
+	for _, u := range cfg.WorkerURLs {
+		hr.workers = append(hr.workers, &renderWorker{
+			url:       u,
+			sem:       make(chan struct{}, concurrency),
+			threshold: threshold,
+			healthy:   true,
+		})
+	}
+	for _, w := range hr.workers {
+		go hr.healthCheckLoop(w)
+	}
+	return hr, nil
+}
+
+// healthCheckLoop periodically probes a tripped worker's /healthz
+// endpoint and restores it to rotation once it responds successfully.
+func (hr *httpRenderer) healthCheckLoop(w *renderWorker) {
+	for range time.Tick(10 * time.Second) {
+		w.mu.Lock()
+		tripped := !w.healthy
+		w.mu.Unlock()
+		if !tripped {
+			continue
+		}
+		resp, err := hr.client.Get(w.url + "/healthz")
+		if err == nil && resp.StatusCode == http.StatusOK {
+			resp.Body.Close()
+			w.mu.Lock()
+			w.healthy = true
+			w.consecFailures = 0
+			w.mu.Unlock()
+			log15.Info("ui: render worker back in rotation", "url", w.url)
+		} else if resp != nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+func (w *renderWorker) recordResult(ok bool, threshold int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if ok {
+		w.consecFailures = 0
+		return
+	}
+	w.consecFailures++
+	if w.consecFailures >= threshold && w.healthy {
+		w.healthy = false
+		log15.Warn("ui: render worker tripped circuit breaker", "url", w.url, "consecutive_failures", w.consecFailures)
+	}
+}
+
+func (w *renderWorker) isHealthy() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.healthy
+}
+
+// pickWorker returns the next healthy worker in round-robin order, or
+// nil if every worker's circuit breaker has tripped.
+func (hr *httpRenderer) pickWorker() *renderWorker {
+	n := len(hr.workers)
+	start := int(atomic.AddUint64(&hr.next, 1))
+	for i := 0; i < n; i++ {
+		w := hr.workers[(start+i)%n]
+		if w.isHealthy() {
+			return w
+		}
+	}
+	return nil
+}
+
+type renderRequest struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Stores json.RawMessage `json:"stores,omitempty"`
+}
+
+type renderResponse struct {
+	StatusCode       int             `json:"statusCode"`
+	ContentType      string          `json:"contentType"`
+	Body             string          `json:"body"`
+	Head             Head            `json:"head"`
+	Stores           json.RawMessage `json:"stores"`
+	RedirectLocation string          `json:"redirectLocation"`
+	Error            string          `json:"error"`
+}
+
+func (hr *httpRenderer) Render(ctx context.Context, r *http.Request) (*RenderResult, error) {
+	w := hr.pickWorker()
+	if w == nil {
+		log15.Warn("ui: all render workers unavailable, falling back to client-only shell")
+		return clientOnlyShell(), nil
+	}
+
+	select {
+	case w.sem <- struct{}{}:
+		defer func() { <-w.sem }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hr.timeout)
+	defer cancel()
+
+	body, err := json.Marshal(renderRequest{Method: r.Method, URL: r.URL.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ctxhttp.Post(ctx, hr.client, w.url+"/render", "application/json", bytes.NewReader(body))
+	if err != nil {
+		w.recordResult(false, w.threshold)
+		if err == context.DeadlineExceeded {
+			return nil, err
+		}
+		return nil, fmt.Errorf("ui: render worker %s: %s", w.url, err)
+	}
+	defer resp.Body.Close()
+
+	var rr renderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
+		w.recordResult(false, w.threshold)
+		return nil, fmt.Errorf("ui: decoding render worker %s response: %s", w.url, err)
+	}
+	w.recordResult(resp.StatusCode < 500, w.threshold)
+
+	return &RenderResult{
+		StatusCode:       rr.StatusCode,
+		ContentType:      rr.ContentType,
+		Body:             rr.Body,
+		Head:             rr.Head,
+		Stores:           rr.Stores,
+		RedirectLocation: rr.RedirectLocation,
+		Error:            rr.Error,
+	}, nil
+}
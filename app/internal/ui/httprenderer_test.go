@@ -0,0 +1,87 @@
+package ui
+
+import "testing"
+
+func newTestWorker(url string, threshold int) *renderWorker {
+	return &renderWorker{
+		url:       url,
+		sem:       make(chan struct{}, 1),
+		threshold: threshold,
+		healthy:   true,
+	}
+}
+
+func TestRenderWorker_RecordResult_TripsAfterThreshold(t *testing.T) {
+	w := newTestWorker("http://worker", 3)
+
+	w.recordResult(false, w.threshold)
+	w.recordResult(false, w.threshold)
+	if !w.isHealthy() {
+		t.Fatal("worker tripped before reaching threshold consecutive failures")
+	}
+
+	w.recordResult(false, w.threshold)
+	if w.isHealthy() {
+		t.Fatal("worker did not trip after reaching threshold consecutive failures")
+	}
+}
+
+func TestRenderWorker_RecordResult_SuccessResetsFailures(t *testing.T) {
+	w := newTestWorker("http://worker", 3)
+
+	w.recordResult(false, w.threshold)
+	w.recordResult(false, w.threshold)
+	w.recordResult(true, w.threshold)
+	if w.consecFailures != 0 {
+		t.Fatalf("consecFailures = %d after a success, want 0", w.consecFailures)
+	}
+
+	w.recordResult(false, w.threshold)
+	w.recordResult(false, w.threshold)
+	if !w.isHealthy() {
+		t.Fatal("worker tripped even though the success reset its failure streak")
+	}
+}
+
+func TestRenderWorker_RecordResult_AlreadyTrippedStaysTripped(t *testing.T) {
+	w := newTestWorker("http://worker", 1)
+	w.recordResult(false, w.threshold)
+	if w.isHealthy() {
+		t.Fatal("worker did not trip on first failure with threshold 1")
+	}
+
+	// A further failure shouldn't panic or otherwise misbehave once
+	// already tripped; the worker only recovers via a health check.
+	w.recordResult(false, w.threshold)
+	if w.isHealthy() {
+		t.Fatal("tripped worker unexpectedly reports healthy")
+	}
+}
+
+func TestHTTPRenderer_PickWorker_SkipsUnhealthy(t *testing.T) {
+	healthy := newTestWorker("http://healthy", 1)
+	tripped := newTestWorker("http://tripped", 1)
+	tripped.healthy = false
+
+	hr := &httpRenderer{workers: []*renderWorker{tripped, healthy}}
+
+	for i := 0; i < 4; i++ {
+		w := hr.pickWorker()
+		if w != healthy {
+			t.Fatalf("pickWorker() = %v, want the only healthy worker", w)
+		}
+	}
+}
+
+func TestHTTPRenderer_PickWorker_AllTrippedReturnsNil(t *testing.T) {
+	a := newTestWorker("http://a", 1)
+	a.healthy = false
+	b := newTestWorker("http://b", 1)
+	b.healthy = false
+
+	hr := &httpRenderer{workers: []*renderWorker{a, b}}
+
+	if w := hr.pickWorker(); w != nil {
+		t.Fatalf("pickWorker() = %v, want nil when every worker's circuit breaker has tripped", w)
+	}
+}
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// inProcessRenderer is the original SSR backend: it runs the render in
+// the same process using the embedded JS engine, which must have been
+// wired into jsEngine (typically by a build-tag-gated file that links
+// the real engine) before it is used.
+type inProcessRenderer struct{}
+
+func (inProcessRenderer) Render(ctx context.Context, r *http.Request) (*RenderResult, error) {
+	return renderInProcess(ctx, r)
+}
+
+// errNoJSEngine is returned instead of nil-panicking when jsEngine has
+// not been wired up, e.g. a build without the embedded engine linked
+// in.
+var errNoJSEngine = errors.New("ui: no embedded JS render engine configured (jsEngine is nil); link the engine or Configure a different Renderer")
+
+// renderInProcess runs the React server-side render in-process using
+// the embedded JS engine. It respects ctx's deadline: if the engine
+// has not returned by the time ctx is done, it returns ctx.Err()
+// (typically context.DeadlineExceeded) so callers can distinguish a
+// timeout from a render that legitimately produced an error status.
+func renderInProcess(ctx context.Context, r *http.Request) (*RenderResult, error) {
+	if jsEngine == nil {
+		return nil, errNoJSEngine
+	}
+
+	type result struct {
+		res *RenderResult
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := jsEngine.Render(r)
+		done <- result{res, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case d := <-done:
+		return d.res, d.err
+	}
+}
+
+// jsEngine is the embedded JS engine used to render the React router
+// tree for a request. It is a package-level var (rather than a
+// parameter threaded through RenderRouter) so that it can be swapped
+// out in tests, and is nil until something wires it up (see
+// errNoJSEngine).
+var jsEngine interface {
+	Render(r *http.Request) (*RenderResult, error)
+}
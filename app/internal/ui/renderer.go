@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Renderer performs the actual React server-side render for a route.
+// RenderRouter delegates to whichever Renderer is configured via
+// Configure, so the SSR backend (in-process JS engine vs. a remote
+// Node worker pool) can be swapped without touching callers.
+type Renderer interface {
+	Render(ctx context.Context, r *http.Request) (*RenderResult, error)
+}
+
+// activeRenderer is the Renderer RenderRouter delegates to. It
+// defaults to the in-process JS engine, matching historical behavior.
+var activeRenderer Renderer = inProcessRenderer{}
+
+// Config selects and configures the SSR backend.
+type Config struct {
+	// Backend is either "inprocess" (default) or "http".
+	Backend string
+
+	// WorkerURLs are the base URLs of the remote Node prerender
+	// workers to use when Backend is "http".
+	WorkerURLs []string
+
+	// WorkerConcurrency caps the number of concurrent render requests
+	// sent to any single worker.
+	WorkerConcurrency int
+
+	// RenderTimeout bounds how long a single render request to a
+	// worker is allowed to take when Backend is "http" (default
+	// 2500ms). It is applied on top of the caller-supplied ctx, so
+	// whichever deadline is earlier wins. The "inprocess" backend
+	// ignores it.
+	RenderTimeout time.Duration
+
+	// CircuitBreakerThreshold is the number of consecutive
+	// failures/timeouts on a worker before it is taken out of
+	// rotation until its next successful health check.
+	CircuitBreakerThreshold int
+}
+
+// Configure sets the Renderer used by RenderRouter according to cfg.
+// It is safe to call once at startup; it is not safe to call
+// concurrently with in-flight renders.
+func Configure(cfg Config) error {
+	switch cfg.Backend {
+	case "", "inprocess":
+		if jsEngine == nil {
+			return errNoJSEngine
+		}
+		activeRenderer = inProcessRenderer{}
+		return nil
+	case "http":
+		r, err := newHTTPRenderer(cfg)
+		if err != nil {
+			return err
+		}
+		activeRenderer = r
+		return nil
+	default:
+		return fmt.Errorf("ui: unknown render backend %q", cfg.Backend)
+	}
+}
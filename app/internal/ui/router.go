@@ -0,0 +1,116 @@
+// Package ui implements server-side rendering of the React/Redux
+// frontend: given an incoming request it runs the client-side router
+// through a configured Renderer (by default the embedded JS engine,
+// which must be linked in via jsEngine) and returns the resulting
+// markup, head tags, and Redux store state needed to hydrate on the
+// client.
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"gopkg.in/inconshreveable/log15.v2"
+
+	"sourcegraph.com/sourcegraph/sourcegraph/app/internal/metrics"
+)
+
+// Head holds the document head tags (title, meta, etc.) produced by
+// the React render for a given route.
+type Head struct {
+	Title string
+	Meta  map[string]string
+}
+
+// RenderResult is the outcome of rendering a route. Error is only set
+// when StatusCode indicates failure, and Body is empty in that case.
+type RenderResult struct {
+	StatusCode       int
+	ContentType      string
+	Body             string
+	Head             Head
+	Stores           json.RawMessage
+	RedirectLocation string
+	Error            string
+
+	// NoStore, when set, tells callers (e.g. the app package's SSR
+	// response cache) that this result must never be cached, for
+	// routes whose output is request-specific in a way the cache key
+	// doesn't capture.
+	NoStore bool
+}
+
+type contextKey int
+
+const disablePrerenderingKey contextKey = iota
+
+// DisabledReactPrerendering returns a copy of ctx that causes
+// RenderRouter to skip React prerendering (whatever the configured
+// Renderer) and instead
+// return a client-only shell. It is used for the SG_DISABLE_JSSERVER
+// kill switch.
+func DisabledReactPrerendering(ctx context.Context) context.Context {
+	return context.WithValue(ctx, disablePrerenderingKey, true)
+}
+
+func prerenderingDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(disablePrerenderingKey).(bool)
+	return disabled
+}
+
+// clientOnlyShell is the RenderResult returned whenever React
+// prerendering is skipped, whether because it was explicitly disabled
+// via DisabledReactPrerendering or because the configured Renderer's
+// circuit breaker has tripped. The client hydrates and renders the
+// route itself in this case.
+func clientOnlyShell() *RenderResult {
+	return &RenderResult{
+		StatusCode:  http.StatusOK,
+		ContentType: "text/html; charset=utf-8",
+		Body:        "",
+		Stores:      json.RawMessage("{}"),
+	}
+}
+
+// RenderOpt controls how RenderRouter renders a route. A nil *RenderOpt
+// is equivalent to the zero value.
+type RenderOpt struct{}
+
+// RenderRouter renders the route matching r using the React client
+// router, either through the configured Renderer (see Configure) or
+// (if prerendering has been disabled via DisabledReactPrerendering) by
+// returning a client-only shell that hydrates and renders on the
+// client instead.
+//
+// RenderRouter returns a non-nil error only when it was unable to
+// produce any RenderResult at all (e.g. ctx expired before the
+// in-process JS engine returned); callers should treat that as a
+// server error rather than inspecting RenderResult.Error, which is
+// reserved for render failures that still produced a status code.
+func RenderRouter(ctx context.Context, r *http.Request, opt *RenderOpt) (*RenderResult, error) {
+	if prerenderingDisabled(ctx) {
+		return clientOnlyShell(), nil
+	}
+
+	start := time.Now()
+	res, err := activeRenderer.Render(ctx, r)
+	metrics.PrerenderDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			metrics.PrerenderTimeouts.Inc()
+		}
+		return nil, err
+	}
+	if res.StatusCode == 0 {
+		return nil, fmt.Errorf("ui: render of %s returned no status code", r.URL)
+	}
+	if _, _, _, isServerErr := metrics.ClassifyStatus(res.StatusCode); isServerErr && res.Body == "" && res.Error == "" {
+		log15.Warn("ui: render returned empty error body for server error status", "URL", r.URL, "status", res.StatusCode)
+	}
+	metrics.StorePayloadSize.Observe(float64(len(res.Stores)))
+	return res, nil
+}
@@ -0,0 +1,11 @@
+package app
+
+import "sourcegraph.com/sourcegraph/sourcegraph/app/internal/metrics"
+
+// serveMetrics exposes the Prometheus registry (app package
+// instrumentation plus any other package registered against the
+// default registry) at the /metrics route, registered by Router in
+// router.go. Unlike serveUI it's a plain http.Handler rather than the
+// package's func(w, r) error convention, since it's promhttp's stock
+// handler and has no app-specific error to propagate.
+var serveMetrics = metrics.Handler()
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"net/http"
+
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// errorHandler adapts the app package's func(w, r) error handler
+// convention (used by serveUI) to http.Handler, logging any error the
+// handler returns. By the time a handler returns an error it has
+// already written a response (see serveUIError), so this is purely
+// for visibility, not for producing the response itself.
+func errorHandler(h func(w http.ResponseWriter, r *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := h(w, r); err != nil {
+			log15.Error("app: handler error", "URL", r.URL, "err", err)
+		}
+	})
+}
+
+// Router serves the app package's routes: the SSR'd UI at "/" and the
+// Prometheus metrics registry at "/metrics".
+var Router = newRouter()
+
+func newRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", serveMetrics)
+	mux.Handle("/", errorHandler(serveUI))
+	return mux
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"gopkg.in/inconshreveable/log15.v2"
+
+	"sourcegraph.com/sourcegraph/sourcegraph/app/internal/metrics"
+	"sourcegraph.com/sourcegraph/sourcegraph/app/internal/ui"
+	"sourcegraph.com/sourcegraph/sourcegraph/auth"
+)
+
+// ssrCacheTTL is how long a cached render is served as fresh.
+// ssrCacheStaleWindow is how much longer, after ttl, a cached render
+// is still served immediately while a background re-render refills it.
+const (
+	ssrCacheTTL         = 30 * time.Second
+	ssrCacheStaleWindow = 5 * time.Minute
+)
+
+// ssrCacheRefreshConcurrency bounds how many background re-renders can
+// run at once, so a burst of stale hits can't stack up SSR renders on
+// top of live request load.
+const ssrCacheRefreshConcurrency = 4
+
+// ssrCacheMaxEntries bounds the cache's memory footprint: since the key
+// includes a per-session auth bucket, the number of distinct keys is
+// not bounded by the number of routes, so without a cap the map would
+// grow without limit. set evicts the oldest entry before an insert
+// that would exceed this, and sweepExpired periodically clears entries that have
+// fallen out of the stale window but were never evicted or re-hit.
+const ssrCacheMaxEntries = 10000
+
+// ssrCacheSweepInterval is how often sweepLoop clears expired entries.
+const ssrCacheSweepInterval = 5 * time.Minute
+
+// ssrVaryHeaders are the request headers, beyond method/URL/auth
+// bucket, that vary a route's rendered output and so must be folded
+// into the cache key.
+var ssrVaryHeaders = []string{"Accept-Language"}
+
+type ssrCacheEntry struct {
+	res        *ui.RenderResult
+	renderedAt time.Time
+}
+
+func (e *ssrCacheEntry) age() time.Duration { return time.Since(e.renderedAt) }
+
+type ssrCacheState int
+
+const (
+	ssrCacheMiss ssrCacheState = iota
+	ssrCacheFresh
+	ssrCacheStale
+)
+
+// ssrCache is an in-process, stale-while-revalidate cache of
+// ui.RenderResults in front of ui.RenderRouter, keyed by method + URL
+// + a coarse auth bucket. It amortizes the SSR tail on hot,
+// non-personalized pages; see cacheKey for what distinguishes entries.
+type ssrCache struct {
+	mu      sync.Mutex
+	entries map[string]*ssrCacheEntry
+	refresh chan struct{} // bounds concurrent background re-renders
+}
+
+var globalSSRCache = newSSRCache()
+
+func newSSRCache() *ssrCache {
+	c := &ssrCache{
+		entries: make(map[string]*ssrCacheEntry),
+		refresh: make(chan struct{}, ssrCacheRefreshConcurrency),
+	}
+	go c.sweepLoop()
+	return c
+}
+
+// sweepLoop periodically removes entries that have aged out of the
+// stale-while-revalidate window, so routes/sessions that stop being
+// requested don't linger in the map forever.
+func (c *ssrCache) sweepLoop() {
+	for range time.Tick(ssrCacheSweepInterval) {
+		c.sweepExpired()
+	}
+}
+
+func (c *ssrCache) sweepExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if e.age() > ssrCacheTTL+ssrCacheStaleWindow {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// evictOldestLocked removes the single oldest entry. Called with c.mu
+// held, when the cache is at ssrCacheMaxEntries, to bound memory use
+// even when entries are still within their stale window (e.g. many
+// distinct sessions hitting many distinct URLs).
+func (c *ssrCache) evictOldestLocked() {
+	var oldestKey string
+	var oldestAt time.Time
+	for key, e := range c.entries {
+		if oldestKey == "" || e.renderedAt.Before(oldestAt) {
+			oldestKey, oldestAt = key, e.renderedAt
+		}
+	}
+	if oldestKey != "" {
+		delete(c.entries, oldestKey)
+	}
+}
+
+func (c *ssrCache) get(key string) (*ssrCacheEntry, ssrCacheState) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		return nil, ssrCacheMiss
+	}
+	switch age := e.age(); {
+	case age <= ssrCacheTTL:
+		return e, ssrCacheFresh
+	case age <= ssrCacheTTL+ssrCacheStaleWindow:
+		return e, ssrCacheStale
+	default:
+		return nil, ssrCacheMiss
+	}
+}
+
+// set stores res under key, unless res opted out via NoStore or isn't
+// a plain success: redirects, client errors, and server errors are
+// never cached, since a 5xx cached for the full TTL would keep
+// re-serving a transient upstream failure, and redirect/error targets
+// are route-specific enough that caching them isn't worth the risk.
+func (c *ssrCache) set(key string, res *ui.RenderResult) {
+	if res.NoStore {
+		return
+	}
+	if isSuccess, _, _, _ := metrics.ClassifyStatus(res.StatusCode); !isSuccess {
+		return
+	}
+	c.mu.Lock()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= ssrCacheMaxEntries {
+		c.evictOldestLocked()
+	}
+	c.entries[key] = &ssrCacheEntry{res: res, renderedAt: time.Now()}
+	c.mu.Unlock()
+}
+
+// refreshAsync re-renders key in the background, bounded by
+// c.refresh, and stores the result for subsequent requests. ctx must
+// be a detached identity context (see detachedIdentity), not one tied
+// to the original request — by the time this runs, that request has
+// already been responded to. It is a no-op if the refresh semaphore is
+// saturated, so a thundering herd of stale hits degrades to "served
+// stale a bit longer" rather than piling up renders.
+func (c *ssrCache) refreshAsync(ctx context.Context, r *http.Request, key string) {
+	select {
+	case c.refresh <- struct{}{}:
+	default:
+		return
+	}
+	metrics.SSRCacheRefreshTotal.Inc()
+	go func() {
+		defer func() { <-c.refresh }()
+		renderCtx, cancel := renderContext(ctx)
+		defer cancel()
+		res, err := ui.RenderRouter(renderCtx, r, nil)
+		if err != nil {
+			log15.Warn("ssr cache: background refresh failed", "URL", r.URL, "err", err)
+			return
+		}
+		c.set(key, res)
+	}()
+}
+
+func cacheStateLabel(state ssrCacheState) string {
+	if state == ssrCacheStale {
+		return "stale"
+	}
+	return "hit"
+}
+
+// cacheKey identifies a cacheable render: the method, full URL, a
+// coarse auth bucket derived from ctx's resolved actor (anonymous vs.
+// a hash of the authenticated user ID), and any declared vary headers.
+func cacheKey(ctx context.Context, r *http.Request) string {
+	h := fnv.New64a()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(authBucket(ctx)))
+	for _, name := range ssrVaryHeaders {
+		h.Write([]byte{0})
+		h.Write([]byte(r.Header.Get(name)))
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// authBucket coarsely buckets ctx's resolved actor for cache
+// partitioning: "anon" for anonymous requests, or a hash of the
+// authenticated user ID otherwise. It deliberately hashes rather than
+// stores the ID, so the cache value itself never needs to carry it.
+func authBucket(ctx context.Context) string {
+	actor := auth.ActorFromContext(ctx)
+	if actor == nil || actor.UID == "" {
+		return "anon"
+	}
+	h := fnv.New64a()
+	h.Write([]byte(actor.UID))
+	return fmt.Sprintf("u%x", h.Sum64())
+}
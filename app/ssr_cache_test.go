@@ -0,0 +1,146 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"sourcegraph.com/sourcegraph/sourcegraph/app/internal/ui"
+	"sourcegraph.com/sourcegraph/sourcegraph/auth"
+)
+
+func TestAuthBucket(t *testing.T) {
+	if got := authBucket(context.Background()); got != "anon" {
+		t.Errorf("authBucket(no actor) = %q, want %q", got, "anon")
+	}
+
+	ctx := auth.WithActor(context.Background(), &auth.Actor{UID: "u1"})
+	bucket := authBucket(ctx)
+	if bucket == "anon" {
+		t.Error("authBucket(authenticated actor) = \"anon\", want a per-user bucket")
+	}
+	if got := authBucket(ctx); got != bucket {
+		t.Errorf("authBucket is not stable across calls for the same actor: %q != %q", got, bucket)
+	}
+
+	other := auth.WithActor(context.Background(), &auth.Actor{UID: "u2"})
+	if authBucket(other) == bucket {
+		t.Error("authBucket gave the same bucket for two different user IDs")
+	}
+}
+
+func TestCacheKey_VariesByAuthBucket(t *testing.T) {
+	r := httptest.NewRequest("GET", "/foo", nil)
+	anon := cacheKey(context.Background(), r)
+	authed := cacheKey(auth.WithActor(context.Background(), &auth.Actor{UID: "u1"}), r)
+	if anon == authed {
+		t.Error("cacheKey gave the same key for an anonymous and an authenticated request to the same URL")
+	}
+}
+
+func TestCacheKey_VariesByMethodURLAndVaryHeader(t *testing.T) {
+	base := httptest.NewRequest("GET", "/foo", nil)
+	diffURL := httptest.NewRequest("GET", "/bar", nil)
+	diffMethod := httptest.NewRequest("POST", "/foo", nil)
+	diffLang := httptest.NewRequest("GET", "/foo", nil)
+	diffLang.Header.Set("Accept-Language", "fr")
+
+	ctx := context.Background()
+	keys := map[string]string{
+		"base":       cacheKey(ctx, base),
+		"diffURL":    cacheKey(ctx, diffURL),
+		"diffMethod": cacheKey(ctx, diffMethod),
+		"diffLang":   cacheKey(ctx, diffLang),
+	}
+	seen := make(map[string]string)
+	for name, key := range keys {
+		if other, ok := seen[key]; ok {
+			t.Errorf("%s and %s produced the same cache key %q", name, other, key)
+		}
+		seen[key] = name
+	}
+}
+
+func TestSSRCache_GetStates(t *testing.T) {
+	c := &ssrCache{entries: make(map[string]*ssrCacheEntry), refresh: make(chan struct{}, 1)}
+	res := &ui.RenderResult{StatusCode: http.StatusOK}
+
+	if _, state := c.get("missing"); state != ssrCacheMiss {
+		t.Errorf("get() on empty cache = %v, want ssrCacheMiss", state)
+	}
+
+	c.entries["fresh"] = &ssrCacheEntry{res: res, renderedAt: time.Now()}
+	if _, state := c.get("fresh"); state != ssrCacheFresh {
+		t.Errorf("get() just-set entry = %v, want ssrCacheFresh", state)
+	}
+
+	c.entries["stale"] = &ssrCacheEntry{res: res, renderedAt: time.Now().Add(-(ssrCacheTTL + time.Second))}
+	if _, state := c.get("stale"); state != ssrCacheStale {
+		t.Errorf("get() entry past TTL but within stale window = %v, want ssrCacheStale", state)
+	}
+
+	c.entries["expired"] = &ssrCacheEntry{res: res, renderedAt: time.Now().Add(-(ssrCacheTTL + ssrCacheStaleWindow + time.Second))}
+	if _, state := c.get("expired"); state != ssrCacheMiss {
+		t.Errorf("get() entry past the stale window = %v, want ssrCacheMiss", state)
+	}
+}
+
+func TestSSRCache_Set_SkipsNonSuccessAndNoStore(t *testing.T) {
+	c := &ssrCache{entries: make(map[string]*ssrCacheEntry), refresh: make(chan struct{}, 1)}
+
+	c.set("redirect", &ui.RenderResult{StatusCode: http.StatusFound})
+	c.set("clienterr", &ui.RenderResult{StatusCode: http.StatusNotFound})
+	c.set("servererr", &ui.RenderResult{StatusCode: http.StatusInternalServerError})
+	c.set("nostore", &ui.RenderResult{StatusCode: http.StatusOK, NoStore: true})
+	if len(c.entries) != 0 {
+		t.Errorf("set() cached %d non-cacheable results, want 0", len(c.entries))
+	}
+
+	c.set("ok", &ui.RenderResult{StatusCode: http.StatusOK})
+	if _, state := c.get("ok"); state != ssrCacheFresh {
+		t.Error("set() did not cache a plain 2xx result")
+	}
+}
+
+func TestSSRCache_Set_EvictsOldestWhenFull(t *testing.T) {
+	c := &ssrCache{entries: make(map[string]*ssrCacheEntry), refresh: make(chan struct{}, 1)}
+
+	// Fill to capacity, with "oldest" predating every other entry.
+	base := time.Now().Add(-time.Hour)
+	c.entries["oldest"] = &ssrCacheEntry{res: &ui.RenderResult{StatusCode: http.StatusOK}, renderedAt: base}
+	for i := 1; i < ssrCacheMaxEntries; i++ {
+		key := fmt.Sprintf("filler-%d", i)
+		c.entries[key] = &ssrCacheEntry{res: &ui.RenderResult{StatusCode: http.StatusOK}, renderedAt: base.Add(time.Duration(i) * time.Second)}
+	}
+
+	c.set("newest", &ui.RenderResult{StatusCode: http.StatusOK})
+
+	if len(c.entries) != ssrCacheMaxEntries {
+		t.Fatalf("len(entries) = %d after set() at capacity, want %d", len(c.entries), ssrCacheMaxEntries)
+	}
+	if _, ok := c.entries["oldest"]; ok {
+		t.Error("set() did not evict the oldest entry once at capacity")
+	}
+	if _, ok := c.entries["newest"]; !ok {
+		t.Error("set() did not store the new entry")
+	}
+}
+
+func TestSSRCache_SweepExpired(t *testing.T) {
+	c := &ssrCache{entries: make(map[string]*ssrCacheEntry), refresh: make(chan struct{}, 1)}
+	c.entries["fresh"] = &ssrCacheEntry{res: &ui.RenderResult{StatusCode: http.StatusOK}, renderedAt: time.Now()}
+	c.entries["expired"] = &ssrCacheEntry{res: &ui.RenderResult{StatusCode: http.StatusOK}, renderedAt: time.Now().Add(-(ssrCacheTTL + ssrCacheStaleWindow + time.Second))}
+
+	c.sweepExpired()
+
+	if _, ok := c.entries["expired"]; ok {
+		t.Error("sweepExpired() did not remove an entry past the stale window")
+	}
+	if _, ok := c.entries["fresh"]; !ok {
+		t.Error("sweepExpired() removed a fresh entry")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"html/template"
 	"net/http"
 	"os"
+	"runtime"
 	"strings"
 	"time"
 
@@ -14,8 +15,10 @@ import (
 
 	"golang.org/x/net/context"
 
+	"sourcegraph.com/sourcegraph/sourcegraph/app/internal/metrics"
 	"sourcegraph.com/sourcegraph/sourcegraph/app/internal/tmpl"
 	"sourcegraph.com/sourcegraph/sourcegraph/app/internal/ui"
+	"sourcegraph.com/sourcegraph/sourcegraph/auth"
 	"sourcegraph.com/sourcegraph/sourcegraph/util/handlerutil"
 )
 
@@ -26,29 +29,138 @@ var ciFactor = func() int {
 	return 1
 }()
 
-func serveUI(w http.ResponseWriter, r *http.Request) error {
+// renderTimeout bounds how long serveUI waits for ui.RenderRouter
+// before giving up. It defaults to the historical 2500ms*ciFactor
+// budget but can be overridden via SG_REACT_RENDER_TIMEOUT (e.g.
+// "5s"), which is useful when the render backend is a remote worker
+// pool with its own network latency.
+var renderTimeout = func() time.Duration {
+	if v := os.Getenv("SG_REACT_RENDER_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return 2500 * time.Millisecond * time.Duration(ciFactor)
+}()
+
+func init() {
+	// SG_REACT_RENDER_BACKEND selects the SSR backend: "inprocess"
+	// (default) renders in this process; "http" dispatches to the
+	// worker pool named by SG_REACT_RENDER_WORKERS (comma-separated
+	// base URLs).
+	backend := os.Getenv("SG_REACT_RENDER_BACKEND")
+	if backend == "" {
+		return
+	}
+	var workers []string
+	if v := os.Getenv("SG_REACT_RENDER_WORKERS"); v != "" {
+		workers = strings.Split(v, ",")
+	}
+	if err := ui.Configure(ui.Config{
+		Backend:       backend,
+		WorkerURLs:    workers,
+		RenderTimeout: renderTimeout,
+	}); err != nil {
+		log15.Error("app: failed to configure React render backend", "backend", backend, "err", err)
+	}
+}
+
+// errorDebugInfo is the structured payload attached to error responses
+// when handlerutil.DebugMode(r) is set, instead of a raw error string.
+type errorDebugInfo struct {
+	Message string   `json:"message"`
+	Stack   []string `json:"stack,omitempty"`
+}
+
+// newErrorDebugInfo captures err's message together with the stack of
+// the goroutine that is building the error response, so DebugMode
+// shows where the error surfaced instead of just its string.
+func newErrorDebugInfo(err error) *errorDebugInfo {
+	buf := make([]byte, 8192)
+	n := runtime.Stack(buf, false)
+	return &errorDebugInfo{
+		Message: err.Error(),
+		Stack:   strings.Split(strings.TrimRight(string(buf[:n]), "\n"), "\n"),
+	}
+}
+
+// identityContext resolves r's authenticated client context. It is
+// tied to r.Context() (via handlerutil.Client), so it must not be used
+// once r's handler has returned — see detachedIdentity.
+func identityContext(r *http.Request) context.Context {
 	ctx, _ := handlerutil.Client(r)
+	return ctx
+}
+
+// detachedIdentity returns a context carrying the same resolved actor
+// as ctx but rooted in context.Background() instead of r.Context(), so
+// it remains usable after the request that produced ctx has been
+// responded to. net/http cancels r.Context() as soon as its handler
+// returns, and ctx (from identityContext) is a child of it; a
+// background SSR cache refresh that ran after the fact on the
+// original ctx would see it already canceled and fail every time.
+func detachedIdentity(ctx context.Context) context.Context {
+	return auth.WithActor(context.Background(), auth.ActorFromContext(ctx))
+}
 
+// renderContext applies the SG_DISABLE_JSSERVER kill switch and the
+// renderTimeout deadline to ctx. Callers must invoke the returned
+// cancel func.
+func renderContext(ctx context.Context) (context.Context, context.CancelFunc) {
 	if v := os.Getenv("SG_DISABLE_JSSERVER"); v != "" {
 		ctx = ui.DisabledReactPrerendering(ctx)
+		metrics.JSServerFallbackTotal.Inc()
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 2500*time.Millisecond*time.Duration(ciFactor))
+	return context.WithTimeout(ctx, renderTimeout)
+}
+
+// serveUI is instrumented with per-route request count, in-flight,
+// response size, and latency metrics; see app/internal/metrics.
+var serveUI = metrics.Instrument("ui", serveUIUninstrumented)
+
+func serveUIUninstrumented(w http.ResponseWriter, r *http.Request) error {
+	identCtx := identityContext(r)
+	key := cacheKey(identCtx, r)
+	if entry, state := globalSSRCache.get(key); state != ssrCacheMiss {
+		metrics.SSRCacheResult.WithLabelValues(cacheStateLabel(state)).Inc()
+		if state == ssrCacheStale {
+			globalSSRCache.refreshAsync(detachedIdentity(identCtx), r, key)
+		}
+		return writeRenderResult(w, r, entry.res, entry.renderedAt)
+	}
+	metrics.SSRCacheResult.WithLabelValues("miss").Inc()
+
+	ctx, cancel := renderContext(identCtx)
 	defer cancel()
 
-	var statusCode int
 	res, err := ui.RenderRouter(ctx, r, nil)
 	if err != nil {
+		var statusCode int
 		switch err {
 		case context.DeadlineExceeded:
-			log15.Warn("Context deadline exceeded for rendering React component, returning early", "URL", r.URL)
-			statusCode = http.StatusAccepted
+			log15.Warn("Context deadline exceeded for rendering React component", "URL", r.URL)
+			statusCode = http.StatusGatewayTimeout
 		default:
-			// TODO Return err so it appropriately triggers a response with a 500 status.
 			log15.Warn("Error rendering React component on the server", "err", err, "URL", r.URL)
+			statusCode = http.StatusInternalServerError
 		}
+		return serveUIError(w, r, statusCode, err)
 	}
 
+	renderedAt := time.Now()
+	globalSSRCache.set(key, res)
+
+	return writeRenderResult(w, r, res, renderedAt)
+}
+
+// writeRenderResult turns a ui.RenderResult into the ui.html response,
+// shared by both a freshly rendered route and one served from the SSR
+// cache. renderedAt is used to compute the Last-Modified header and is
+// either the time of this render or, for a cache hit, the time the
+// cached entry was originally rendered.
+func writeRenderResult(w http.ResponseWriter, r *http.Request, res *ui.RenderResult, renderedAt time.Time) error {
+	var statusCode int
 	var header http.Header
 	var data struct {
 		tmpl.Common
@@ -56,39 +168,93 @@ func serveUI(w http.ResponseWriter, r *http.Request) error {
 		Body   template.HTML
 		Stores *json.RawMessage
 
-		ErrorTitle     string
-		ErrorDebugInfo string
+		ErrorTitle string
+		ErrorDebug *errorDebugInfo
 	}
 
-	if res != nil {
-		statusCode = res.StatusCode
-		data.Stores = &res.Stores
-		data.Head = &res.Head
+	statusCode = res.StatusCode
+	data.Stores = &res.Stores
+	data.Head = &res.Head
 
-		if strings.HasPrefix(res.ContentType, "text/html") {
-			data.Body = template.HTML(res.Body)
-		} else if res.StatusCode >= 300 && res.StatusCode <= 399 {
-			// Nothing to do; we set the Location header below.
-		} else if res.Body == "" && res.StatusCode >= 400 {
-			data.ErrorTitle = fmt.Sprintf("HTTP %d %s", res.StatusCode, http.StatusText(res.StatusCode))
-			if handlerutil.DebugMode(r) {
-				data.ErrorDebugInfo = res.Error
-			}
-		} else {
-			return errors.New("ui render router response is neither text/html nor an error")
+	isSuccess, isRedirect, isClientErr, isServerErr := metrics.ClassifyStatus(statusCode)
+	switch {
+	case strings.HasPrefix(res.ContentType, "text/html"):
+		data.Body = template.HTML(res.Body)
+	case isRedirect:
+		// Nothing to do; we set the Location header below.
+	case res.Body == "" && (isClientErr || isServerErr):
+		data.ErrorTitle = fmt.Sprintf("HTTP %d %s", statusCode, http.StatusText(statusCode))
+		if handlerutil.DebugMode(r) {
+			data.ErrorDebug = newErrorDebugInfo(errors.New(res.Error))
 		}
+	default:
+		return serveUIError(w, r, http.StatusInternalServerError, errors.New("ui render router response is neither text/html nor an error"))
+	}
 
-		header = make(http.Header)
-		header.Set("content-type", res.ContentType)
-		if res.RedirectLocation != "" {
-			header.Set("location", res.RedirectLocation)
+	header = make(http.Header)
+	header.Set("content-type", res.ContentType)
+	if res.RedirectLocation != "" {
+		header.Set("location", res.RedirectLocation)
+	}
+
+	// ETag/Last-Modified are only computed for a 2xx body. Gating on
+	// isSuccess (rather than data.ErrorTitle, which is only set for the
+	// empty-body error case) matters: a React-rendered 4xx/5xx page has
+	// a text/html body and an empty ErrorTitle, and a 304 short-circuit
+	// below would otherwise return before the isServerErr check further
+	// down ever ran, silently swallowing the error instead of
+	// propagating it. A render that hit context.DeadlineExceeded never
+	// reaches here (it's handled as an error response above).
+	if isSuccess {
+		etag := computeETag(res)
+		header.Set("etag", etag)
+		header.Set("last-modified", renderedAt.UTC().Format(http.TimeFormat))
+		if condGETSatisfied(r, etag, renderedAt) {
+			for name, values := range header {
+				for _, v := range values {
+					w.Header().Add(name, v)
+				}
+			}
+			w.WriteHeader(http.StatusNotModified)
+			return nil
 		}
 	}
 
-	if statusCode == 0 || statusCode == 500 {
-		// TODO Return a http.StatusInternalServerError response instead of pretending everything went ok.
-		statusCode = http.StatusAccepted
+	if isServerErr {
+		return serveUIError(w, r, statusCode, errors.New(res.Error))
 	}
 
 	return tmpl.Exec(r, w, "ui.html", statusCode, header, &data)
 }
+
+// serveUIError renders statusCode/err as either a structured JSON
+// error body (for clients that negotiate application/json) or the
+// standard HTML error page, and returns err so that it propagates to
+// the handler middleware for logging/metrics instead of being
+// swallowed here.
+func serveUIError(w http.ResponseWriter, r *http.Request, statusCode int, err error) error {
+	data := struct {
+		tmpl.Common
+		ErrorTitle string
+		ErrorDebug *errorDebugInfo
+	}{
+		ErrorTitle: fmt.Sprintf("HTTP %d %s", statusCode, http.StatusText(statusCode)),
+	}
+	if handlerutil.DebugMode(r) && err != nil {
+		data.ErrorDebug = newErrorDebugInfo(err)
+	}
+
+	if strings.Contains(r.Header.Get("accept"), "application/json") {
+		w.Header().Set("content-type", "application/json; charset=utf-8")
+		w.WriteHeader(statusCode)
+		if encErr := json.NewEncoder(w).Encode(data); encErr != nil {
+			return encErr
+		}
+		return err
+	}
+
+	if execErr := tmpl.Exec(r, w, "ui.html", statusCode, nil, &data); execErr != nil {
+		return execErr
+	}
+	return err
+}